@@ -1,16 +1,60 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"reflect"
+	"regexp"
 	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/cenkalti/backoff/v4"
+	gocache "github.com/patrickmn/go-cache"
+	"golang.org/x/sync/errgroup"
 )
 
+// describeBatchSize is the maximum number of identifiers the ECS
+// DescribeTasks/DescribeContainerInstances APIs accept per call.
+const describeBatchSize = 100
+
+// defaultConcurrency is the --concurrency default: how many cluster/service
+// scans or Describe* batches may be in flight at once.
+const defaultConcurrency = 4
+
+// eniAttachmentType is the attachment type ECS uses on awsvpc (Fargate)
+// tasks to carry their ENI.
+const eniAttachmentType = "ElasticNetworkInterface"
+
+const (
+	launchTypeEc2     = "ec2"
+	launchTypeFargate = "fargate"
+	launchTypeAll     = "all"
+)
+
+// clustersCacheKey is the go-cache key under which the ListClusters result
+// is cached between polls in watch mode.
+const clustersCacheKey = "clusters"
+
+// scanOptions bounds how aggressively a scan fans out work across clusters,
+// services and Describe* batches, and whether a failure partway through
+// should abort the scan or flush whatever was collected so far.
+type scanOptions struct {
+	concurrency int
+	partial     bool
+}
+
 type service struct {
 	clusterArn *string
 	serviceArn *string
@@ -20,14 +64,228 @@ type task struct {
 	service
 	taskArn              *string
 	containerInstanceArn *string
+	launchType           *string
+	attachments          []*ecs.Attachment
 }
 
 type containerInstance struct {
 	task
 	ec2InstanceId *string
+	eniId         *string
+	privateIpv4   *string
+	publicIpv4    *string
+}
+
+// ec2Instance enriches a containerInstance with the EC2 metadata of the
+// host backing it.
+type ec2Instance struct {
+	containerInstance
+	privateIpAddress *string
+	publicIpAddress  *string
+	availabilityZone *string
+	instanceType     *string
+	tags             map[string]string
+}
+
+// warnPartial reports a stage failure to stderr when the caller asked to
+// keep going with whatever was collected so far.
+func warnPartial(stage string, err error) {
+	fmt.Fprintf(os.Stderr, "warning: partial %s, continuing with results collected so far: %v\n", stage, err)
+}
+
+// buildQueryMatcher compiles serviceQuery into a matching function, once,
+// ahead of the scan. Three forms are supported: a "/regex/" wrapped in
+// slashes, a glob containing "*" or "?", or a plain string compared for
+// exact equality.
+func buildQueryMatcher(serviceQuery string) (func(string) bool, error) {
+	if len(serviceQuery) >= 2 && strings.HasPrefix(serviceQuery, "/") && strings.HasSuffix(serviceQuery, "/") {
+		re, err := regexp.Compile(serviceQuery[1 : len(serviceQuery)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid --query regex: %w", err)
+		}
+		return re.MatchString, nil
+	}
+
+	if strings.ContainsAny(serviceQuery, "*?") {
+		re, err := globToRegexp(serviceQuery)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --query glob: %w", err)
+		}
+		return re.MatchString, nil
+	}
+
+	return func(serviceArn string) bool { return serviceArn == serviceQuery }, nil
+}
+
+// globToRegexp translates a glob pattern ("*" and "?" wildcards, everything
+// else literal) into an anchored regexp.
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// resolveClusterArns returns the clusters to scan: clusterFilters verbatim
+// when given (ECS accepts a cluster name or ARN interchangeably, so no
+// ListClusters call is needed to resolve them), otherwise every cluster in
+// the account.
+func resolveClusterArns(ctx aws.Context, client *ecs.ECS, cache *gocache.Cache, clusterFilters []string) ([]*string, error) {
+	if len(clusterFilters) > 0 {
+		arns := make([]*string, len(clusterFilters))
+		for i, clusterFilter := range clusterFilters {
+			clusterFilter := clusterFilter
+			arns[i] = &clusterFilter
+		}
+		return arns, nil
+	}
+
+	return listClustersCached(ctx, client, cache)
+}
+
+// searchServices lists ECS services across the scanned clusters and keeps
+// the ones matched by serviceQuery (exact, glob or regex), further restricted
+// to clusterFilters and tagFilters when given. cache, when non-nil, avoids
+// re-listing clusters on every call (used by watch mode). Clusters are
+// scanned concurrently, bounded by opts.concurrency; the first failure
+// cancels the rest, but if opts.partial is set the services matched before
+// that point are still returned.
+func searchServices(ctx aws.Context, client *ecs.ECS, serviceQuery string, clusterFilters []string, tagFilters map[string]string, cache *gocache.Cache, opts scanOptions) ([]*service, error) {
+	matches, err := buildQueryMatcher(serviceQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	clustersArns, err := resolveClusterArns(ctx, client, cache, clusterFilters)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu       sync.Mutex
+		services []*service
+	)
+
+	g, gctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, opts.concurrency)
+	for _, clusterArn := range clustersArns {
+		clusterArn := clusterArn
+		sem <- struct{}{}
+		g.Go(func() error {
+			defer func() { <-sem }()
+			return client.ListServicesPagesWithContext(gctx, &ecs.ListServicesInput{Cluster: clusterArn}, func(sOut *ecs.ListServicesOutput, lastPage bool) bool {
+				var matched []*service
+				for _, serviceArn := range sOut.ServiceArns {
+					if matches(*serviceArn) {
+						matched = append(matched, &service{clusterArn: clusterArn, serviceArn: serviceArn})
+					}
+				}
+				if len(matched) > 0 {
+					mu.Lock()
+					services = append(services, matched...)
+					mu.Unlock()
+				}
+				return true
+			})
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		if !opts.partial {
+			return nil, err
+		}
+		warnPartial("service scan", err)
+	}
+
+	if len(tagFilters) == 0 {
+		return services, nil
+	}
+
+	return filterServicesByTags(ctx, client, services, tagFilters, opts)
 }
 
-func searchServices(ctx aws.Context, client *ecs.ECS, serviceQuery string) ([]*service, error) {
+// filterServicesByTags drops any service that doesn't carry every key/value
+// pair in tagFilters, calling ListTagsForResource per candidate concurrently,
+// bounded by opts.concurrency.
+func filterServicesByTags(ctx aws.Context, client *ecs.ECS, services []*service, tagFilters map[string]string, opts scanOptions) ([]*service, error) {
+	var (
+		mu       sync.Mutex
+		filtered []*service
+	)
+
+	g, gctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, opts.concurrency)
+	for _, svc := range services {
+		svc := svc
+		sem <- struct{}{}
+		g.Go(func() error {
+			defer func() { <-sem }()
+
+			var tagsOut *ecs.ListTagsForResourceOutput
+			err := withRetry(gctx, func() error {
+				var innerErr error
+				tagsOut, innerErr = client.ListTagsForResourceWithContext(gctx, &ecs.ListTagsForResourceInput{ResourceArn: svc.serviceArn})
+				return innerErr
+			})
+			if err != nil {
+				return err
+			}
+
+			if tagsMatch(tagsOut.Tags, tagFilters) {
+				mu.Lock()
+				filtered = append(filtered, svc)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		if !opts.partial {
+			return nil, err
+		}
+		warnPartial("tag filter", err)
+	}
+
+	return filtered, nil
+}
+
+// tagsMatch reports whether every key/value pair in required is present in
+// tags.
+func tagsMatch(tags []*ecs.Tag, required map[string]string) bool {
+	got := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		got[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+
+	for k, v := range required {
+		if gv, ok := got[k]; !ok || gv != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// listClustersCached returns every cluster ARN in the account, serving the
+// result from cache (if set and fresh) instead of calling ListClusters again.
+func listClustersCached(ctx aws.Context, client *ecs.ECS, cache *gocache.Cache) ([]*string, error) {
+	if cache != nil {
+		if cached, ok := cache.Get(clustersCacheKey); ok {
+			return cached.([]*string), nil
+		}
+	}
+
 	var clustersArns []*string
 	err := client.ListClustersPagesWithContext(ctx, nil, func(cOut *ecs.ListClustersOutput, lastPage bool) bool {
 		clustersArns = append(clustersArns, cOut.ClusterArns...)
@@ -37,47 +295,126 @@ func searchServices(ctx aws.Context, client *ecs.ECS, serviceQuery string) ([]*s
 		return nil, err
 	}
 
-	var services []*service
-	for _, clusterArn := range clustersArns {
-		err = client.ListServicesPagesWithContext(ctx, &ecs.ListServicesInput{Cluster: clusterArn}, func(sOut *ecs.ListServicesOutput, lastPage bool) bool {
-			for _, serviceArn := range sOut.ServiceArns {
-				if strings.Contains(*serviceArn, serviceQuery) {
-					services = append(services, &service{clusterArn: clusterArn, serviceArn: serviceArn})
-				}
+	if cache != nil {
+		cache.SetDefault(clustersCacheKey, clustersArns)
+	}
+
+	return clustersArns, nil
+}
+
+// listTasks returns the task ARNs currently belonging to svc. Unlike
+// listClustersCached, this is never served from cache: it's the very list
+// watch mode diffs against on every poll, so caching it with a TTL anywhere
+// near --interval would race the ticker and could mask task churn.
+func listTasks(ctx aws.Context, client *ecs.ECS, svc *service) ([]*string, error) {
+	var taskArns []*string
+	err := client.ListTasksPagesWithContext(ctx, &ecs.ListTasksInput{Cluster: svc.clusterArn, ServiceName: svc.serviceArn}, func(tOut *ecs.ListTasksOutput, lastPage bool) bool {
+		taskArns = append(taskArns, tOut.TaskArns...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return taskArns, nil
+}
+
+// chunkStrings splits arns into slices of at most size elements.
+func chunkStrings(arns []*string, size int) [][]*string {
+	var chunks [][]*string
+	for size < len(arns) {
+		arns, chunks = arns[size:], append(chunks, arns[0:size:size])
+	}
+	if len(arns) > 0 {
+		chunks = append(chunks, arns)
+	}
+	return chunks
+}
+
+// isThrottlingError reports whether err is a transient AWS throttling
+// response (ThrottlingException, RequestLimitExceeded) rather than a
+// permanent failure like AccessDenied or ClusterNotFoundException.
+func isThrottlingError(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+
+	switch awsErr.Code() {
+	case "ThrottlingException", "RequestLimitExceeded":
+		return true
+	default:
+		return false
+	}
+}
+
+// withRetry wraps fn with exponential backoff so transient throttling
+// (ThrottlingException, RequestLimitExceeded) doesn't abort the whole scan.
+// Any other error is treated as permanent and returned immediately, so a
+// failure like AccessDenied doesn't sit retrying for backoff's default
+// 15-minute MaxElapsedTime.
+func withRetry(ctx aws.Context, fn func() error) error {
+	wrapped := func() error {
+		if err := fn(); err != nil {
+			if !isThrottlingError(err) {
+				return backoff.Permanent(err)
 			}
-			return true
-		})
-		if err != nil {
-			return nil, err
+			return err
 		}
+		return nil
 	}
 
-	return services, nil
+	return backoff.Retry(wrapped, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
 }
 
-func searchTasks(ctx aws.Context, client *ecs.ECS, services []*service) ([]*task, error) {
-	clustersTasks := make(map[string][]*task)
-	for _, service := range services {
-		err := client.ListTasksPagesWithContext(ctx, &ecs.ListTasksInput{Cluster: service.clusterArn, ServiceName: service.serviceArn}, func(tOut *ecs.ListTasksOutput, lastPage bool) bool {
-			for _, taskArn := range tOut.TaskArns {
-				t := &task{service: *service, taskArn: taskArn}
-				clusterTasks, ok := clustersTasks[*service.clusterArn]
-				if ok {
-					clustersTasks[*service.clusterArn] = append(clusterTasks, t)
-				} else {
-					clustersTasks[*service.clusterArn] = []*task{t}
-				}
+// searchTasks lists the tasks belonging to each service and describes them
+// to fill in their launch type, container instance and attachments. Task
+// lists are always listed fresh (see listTasks) since watch mode diffs
+// against them on every poll. Services are listed concurrently, bounded by
+// opts.concurrency; the first failure cancels the rest, but if opts.partial
+// is set whatever was collected so far is still returned.
+func searchTasks(ctx aws.Context, client *ecs.ECS, services []*service, opts scanOptions) ([]*task, error) {
+	var (
+		mu            sync.Mutex
+		clustersTasks = make(map[string][]*task)
+	)
+
+	g, gctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, opts.concurrency)
+	for _, svc := range services {
+		svc := svc
+		sem <- struct{}{}
+		g.Go(func() error {
+			defer func() { <-sem }()
+			taskArns, err := listTasks(gctx, client, svc)
+			if err != nil {
+				return err
 			}
 
-			return true
+			mu.Lock()
+			for _, taskArn := range taskArns {
+				t := &task{service: *svc, taskArn: taskArn}
+				clustersTasks[*svc.clusterArn] = append(clustersTasks[*svc.clusterArn], t)
+			}
+			mu.Unlock()
+			return nil
 		})
-		if err != nil {
+	}
+
+	if err := g.Wait(); err != nil {
+		if !opts.partial {
 			return nil, err
 		}
+		warnPartial("task listing", err)
 	}
 
 	var result []*task
 	for clusterArn, tasks := range clustersTasks {
+		clusterArn := clusterArn
+		if len(tasks) == 0 {
+			continue
+		}
+
 		var tasksArn []*string
 		taskMap := make(map[string]*task)
 		for _, t := range tasks {
@@ -85,57 +422,677 @@ func searchTasks(ctx aws.Context, client *ecs.ECS, services []*service) ([]*task
 			taskMap[*t.taskArn] = t
 		}
 
-		dOut, err := client.DescribeTasksWithContext(ctx, &ecs.DescribeTasksInput{Cluster: &clusterArn, Tasks: tasksArn})
+		ecsTasks, err := describeTasksChunked(ctx, client, &clusterArn, tasksArn, opts)
 		if err != nil {
-			return nil, err
+			if !opts.partial {
+				return nil, err
+			}
+			warnPartial(fmt.Sprintf("task describe for cluster %s", clusterArn), err)
 		}
 
-		for _, ecsTask := range dOut.Tasks {
+		for _, ecsTask := range ecsTasks {
 			oldTask := taskMap[*ecsTask.TaskArn]
-			result = append(result, &task{service: oldTask.service, taskArn: oldTask.taskArn, containerInstanceArn: ecsTask.ContainerInstanceArn})
+			result = append(result, &task{
+				service:              oldTask.service,
+				taskArn:              oldTask.taskArn,
+				containerInstanceArn: ecsTask.ContainerInstanceArn,
+				launchType:           ecsTask.LaunchType,
+				attachments:          ecsTask.Attachments,
+			})
 		}
 	}
 
 	return result, nil
 }
 
-func searchContainerInstances(ctx aws.Context, client *ecs.ECS, tasks []*task) ([]*containerInstance, error) {
-	clustersTasks := make(map[string][]*task)
+// describeTasksChunked issues DescribeTasksWithContext in batches of at most
+// describeBatchSize ARNs, running up to opts.concurrency batches in parallel
+// and retrying transient failures with backoff. If a batch fails and
+// opts.partial is set, the tasks described before that point are still
+// returned alongside the error.
+func describeTasksChunked(ctx aws.Context, client *ecs.ECS, clusterArn *string, tasksArn []*string, opts scanOptions) ([]*ecs.Task, error) {
+	chunks := chunkStrings(tasksArn, describeBatchSize)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		sem      = make(chan struct{}, opts.concurrency)
+		result   []*ecs.Task
+		firstErr error
+	)
+
+	for _, chunk := range chunks {
+		chunk := chunk
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var dOut *ecs.DescribeTasksOutput
+			err := withRetry(ctx, func() error {
+				var innerErr error
+				dOut, innerErr = client.DescribeTasksWithContext(ctx, &ecs.DescribeTasksInput{Cluster: clusterArn, Tasks: chunk})
+				return innerErr
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			result = append(result, dOut.Tasks...)
+		}()
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		if !opts.partial {
+			return nil, firstErr
+		}
+		return result, firstErr
+	}
+
+	return result, nil
+}
+
+// isFargateTask reports whether t ran on Fargate rather than on a registered
+// EC2 container instance. Tasks started via a capacity provider strategy
+// (including FARGATE_SPOT) come back from DescribeTasks with launchType nil,
+// so a nil containerInstanceArn is also treated as Fargate rather than
+// risking a nil container instance ARN further down the EC2 path.
+func isFargateTask(t *task) bool {
+	if t.launchType != nil && *t.launchType == ecs.LaunchTypeFargate {
+		return true
+	}
+	return t.containerInstanceArn == nil
+}
+
+// searchContainerInstances resolves the EC2 container instance (or, for
+// Fargate tasks, the ENI) backing each task. launchType restricts the scan
+// to "ec2", "fargate" or "all".
+func searchContainerInstances(ctx aws.Context, client *ecs.ECS, ec2Client *ec2.EC2, tasks []*task, launchType string, opts scanOptions) ([]*containerInstance, error) {
+	var ec2Tasks, fargateTasks []*task
 	for _, t := range tasks {
-		clusterTasks, ok := clustersTasks[*t.clusterArn]
-		if ok {
-			clustersTasks[*t.clusterArn] = append(clusterTasks, t)
+		if isFargateTask(t) {
+			fargateTasks = append(fargateTasks, t)
 		} else {
-			clustersTasks[*t.clusterArn] = []*task{t}
+			ec2Tasks = append(ec2Tasks, t)
+		}
+	}
+
+	var result []*containerInstance
+
+	if launchType == launchTypeEc2 || launchType == launchTypeAll {
+		ec2Result, err := searchEc2ContainerInstances(ctx, client, ec2Tasks, opts)
+		if err != nil {
+			if !opts.partial {
+				return nil, err
+			}
+			warnPartial("EC2 container instance scan", err)
+		}
+		result = append(result, ec2Result...)
+	}
+
+	if launchType == launchTypeFargate || launchType == launchTypeAll {
+		fargateResult, err := searchFargateContainerInstances(ctx, ec2Client, fargateTasks, opts)
+		if err != nil {
+			if !opts.partial {
+				return nil, err
+			}
+			warnPartial("Fargate ENI scan", err)
 		}
+		result = append(result, fargateResult...)
+	}
+
+	return result, nil
+}
+
+// searchEc2ContainerInstances resolves the registered EC2 container instance
+// ID for every task that runs on the EC2 launch type.
+func searchEc2ContainerInstances(ctx aws.Context, client *ecs.ECS, tasks []*task, opts scanOptions) ([]*containerInstance, error) {
+	clustersTasks := make(map[string][]*task)
+	for _, t := range tasks {
+		clustersTasks[*t.clusterArn] = append(clustersTasks[*t.clusterArn], t)
 	}
 
 	var result []*containerInstance
+	var firstErr error
 	for clusterArn, clusterTasks := range clustersTasks {
+		clusterArn := clusterArn
+		if len(clusterTasks) == 0 {
+			continue
+		}
+
 		var containerInstancesArn []*string
 		taskMap := make(map[string]*task)
 		for _, t := range clusterTasks {
+			if t.containerInstanceArn == nil {
+				// Not yet placed on a container instance (e.g. still
+				// PROVISIONING/PENDING); nothing to describe.
+				continue
+			}
 			containerInstancesArn = append(containerInstancesArn, t.containerInstanceArn)
 			taskMap[*t.containerInstanceArn] = t
 		}
 
-		ciOut, err := client.DescribeContainerInstancesWithContext(ctx, &ecs.DescribeContainerInstancesInput{Cluster: &clusterArn, ContainerInstances: containerInstancesArn})
+		ecsContainerInstances, err := describeContainerInstancesChunked(ctx, client, &clusterArn, containerInstancesArn, opts)
 		if err != nil {
-			return nil, err
+			if !opts.partial {
+				return nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
 		}
 
-		for _, ci := range ciOut.ContainerInstances {
+		for _, ci := range ecsContainerInstances {
 			oldTask := taskMap[*ci.ContainerInstanceArn]
 			result = append(result, &containerInstance{task: *oldTask, ec2InstanceId: ci.Ec2InstanceId})
 		}
 	}
 
+	return result, firstErr
+}
+
+// searchFargateContainerInstances resolves the ENI and private/public IPv4
+// address for every task that runs on the Fargate launch type, reading the
+// ElasticNetworkInterface attachment off each task rather than calling
+// DescribeContainerInstances (which only covers registered EC2 instances).
+func searchFargateContainerInstances(ctx aws.Context, ec2Client *ec2.EC2, tasks []*task, opts scanOptions) ([]*containerInstance, error) {
+	var result []*containerInstance
+	eniMap := make(map[string]*containerInstance)
+
+	for _, t := range tasks {
+		eniId, privateIpv4 := fargateNetworking(t.attachments)
+		ci := &containerInstance{task: *t, eniId: eniId, privateIpv4: privateIpv4}
+		result = append(result, ci)
+		if eniId != nil {
+			eniMap[*eniId] = ci
+		}
+	}
+
+	if len(eniMap) == 0 {
+		return result, nil
+	}
+
+	var eniIds []*string
+	for eniId := range eniMap {
+		eniId := eniId
+		eniIds = append(eniIds, &eniId)
+	}
+
+	chunks := chunkStrings(eniIds, describeBatchSize)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		sem      = make(chan struct{}, opts.concurrency)
+		firstErr error
+	)
+
+	for _, chunk := range chunks {
+		chunk := chunk
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var dOut *ec2.DescribeNetworkInterfacesOutput
+			err := withRetry(ctx, func() error {
+				var innerErr error
+				dOut, innerErr = ec2Client.DescribeNetworkInterfacesWithContext(ctx, &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: chunk})
+				return innerErr
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+
+			for _, eni := range dOut.NetworkInterfaces {
+				ci := eniMap[*eni.NetworkInterfaceId]
+				if ci == nil || eni.Association == nil {
+					continue
+				}
+				ci.publicIpv4 = eni.Association.PublicIp
+			}
+		}()
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		if !opts.partial {
+			return nil, firstErr
+		}
+		return result, firstErr
+	}
+
 	return result, nil
 }
 
+// fargateNetworking extracts the ENI ID and private IPv4 address from a
+// task's ElasticNetworkInterface attachment, if present.
+func fargateNetworking(attachments []*ecs.Attachment) (eniId *string, privateIpv4 *string) {
+	for _, attachment := range attachments {
+		if attachment.Type == nil || *attachment.Type != eniAttachmentType {
+			continue
+		}
+
+		for _, detail := range attachment.Details {
+			if detail.Name == nil || detail.Value == nil {
+				continue
+			}
+			switch *detail.Name {
+			case "networkInterfaceId":
+				eniId = detail.Value
+			case "privateIPv4Address":
+				privateIpv4 = detail.Value
+			}
+		}
+	}
+
+	return eniId, privateIpv4
+}
+
+// describeContainerInstancesChunked issues DescribeContainerInstancesWithContext
+// in batches of at most describeBatchSize ARNs, running up to
+// opts.concurrency batches in parallel and retrying transient failures with
+// backoff. If a batch fails and opts.partial is set, the container instances
+// described before that point are still returned alongside the error.
+func describeContainerInstancesChunked(ctx aws.Context, client *ecs.ECS, clusterArn *string, containerInstancesArn []*string, opts scanOptions) ([]*ecs.ContainerInstance, error) {
+	chunks := chunkStrings(containerInstancesArn, describeBatchSize)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		sem      = make(chan struct{}, opts.concurrency)
+		result   []*ecs.ContainerInstance
+		firstErr error
+	)
+
+	for _, chunk := range chunks {
+		chunk := chunk
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var ciOut *ecs.DescribeContainerInstancesOutput
+			err := withRetry(ctx, func() error {
+				var innerErr error
+				ciOut, innerErr = client.DescribeContainerInstancesWithContext(ctx, &ecs.DescribeContainerInstancesInput{Cluster: clusterArn, ContainerInstances: chunk})
+				return innerErr
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			result = append(result, ciOut.ContainerInstances...)
+		}()
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		if !opts.partial {
+			return nil, firstErr
+		}
+		return result, firstErr
+	}
+
+	return result, nil
+}
+
+// searchEc2Instances enriches each containerInstance with EC2 metadata
+// (private/public IP, availability zone, instance type, tags) by describing
+// the backing EC2 instances in batches of at most describeBatchSize IDs.
+func searchEc2Instances(ctx aws.Context, client *ec2.EC2, containerInstances []*containerInstance, opts scanOptions) ([]*ec2Instance, error) {
+	var instanceIds []*string
+	var result []*ec2Instance
+	ciMap := make(map[string]*containerInstance)
+	for _, ci := range containerInstances {
+		if ci.ec2InstanceId == nil {
+			// Fargate tasks have no backing EC2 instance to enrich; pass
+			// them through as-is so they still show up in the output.
+			result = append(result, &ec2Instance{containerInstance: *ci})
+			continue
+		}
+		instanceIds = append(instanceIds, ci.ec2InstanceId)
+		ciMap[*ci.ec2InstanceId] = ci
+	}
+
+	if len(instanceIds) == 0 {
+		return result, nil
+	}
+
+	chunks := chunkStrings(instanceIds, describeBatchSize)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		sem      = make(chan struct{}, opts.concurrency)
+		firstErr error
+	)
+
+	for _, chunk := range chunks {
+		chunk := chunk
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var dOut *ec2.DescribeInstancesOutput
+			err := withRetry(ctx, func() error {
+				var innerErr error
+				dOut, innerErr = client.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{InstanceIds: chunk})
+				return innerErr
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+
+			for _, reservation := range dOut.Reservations {
+				for _, instance := range reservation.Instances {
+					ci := ciMap[*instance.InstanceId]
+					if ci == nil {
+						continue
+					}
+
+					tags := make(map[string]string, len(instance.Tags))
+					for _, tag := range instance.Tags {
+						tags[*tag.Key] = *tag.Value
+					}
+
+					result = append(result, &ec2Instance{
+						containerInstance: *ci,
+						privateIpAddress:  instance.PrivateIpAddress,
+						publicIpAddress:   instance.PublicIpAddress,
+						availabilityZone:  instance.Placement.AvailabilityZone,
+						instanceType:      instance.InstanceType,
+						tags:              tags,
+					})
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		if !opts.partial {
+			return nil, firstErr
+		}
+		return result, firstErr
+	}
+
+	return result, nil
+}
+
+// printInstances renders the enriched container instances in the requested
+// output format (json, table or tsv).
+func printInstances(w *os.File, instances []*ec2Instance, output string) error {
+	switch output {
+	case "json":
+		return printInstancesJSON(w, instances)
+	case "tsv":
+		printInstancesTSV(w, instances)
+		return nil
+	default:
+		printInstancesTable(w, instances)
+		return nil
+	}
+}
+
+// instanceRecord is the flat, JSON-friendly projection of an ec2Instance
+// used by the json printer and the watch-mode diff; the tsv and table
+// printers read the ec2Instance fields directly.
+type instanceRecord struct {
+	ClusterArn           string            `json:"clusterArn"`
+	ServiceArn           string            `json:"serviceArn"`
+	TaskArn              string            `json:"taskArn"`
+	ContainerInstanceArn string            `json:"containerInstanceArn"`
+	Ec2InstanceId        string            `json:"ec2InstanceId,omitempty"`
+	EniId                string            `json:"eniId,omitempty"`
+	PrivateIpAddress     string            `json:"privateIpAddress"`
+	PublicIpAddress      string            `json:"publicIpAddress"`
+	AvailabilityZone     string            `json:"availabilityZone,omitempty"`
+	InstanceType         string            `json:"instanceType,omitempty"`
+	Tags                 map[string]string `json:"tags,omitempty"`
+}
+
+// toRecord projects an ec2Instance into its flat instanceRecord form.
+func toRecord(i *ec2Instance) instanceRecord {
+	return instanceRecord{
+		ClusterArn:           aws.StringValue(i.clusterArn),
+		ServiceArn:           aws.StringValue(i.serviceArn),
+		TaskArn:              aws.StringValue(i.taskArn),
+		ContainerInstanceArn: aws.StringValue(i.containerInstanceArn),
+		Ec2InstanceId:        aws.StringValue(i.ec2InstanceId),
+		EniId:                aws.StringValue(i.eniId),
+		PrivateIpAddress:     firstNonEmpty(i.privateIpAddress, i.privateIpv4),
+		PublicIpAddress:      firstNonEmpty(i.publicIpAddress, i.publicIpv4),
+		AvailabilityZone:     aws.StringValue(i.availabilityZone),
+		InstanceType:         aws.StringValue(i.instanceType),
+		Tags:                 i.tags,
+	}
+}
+
+func printInstancesJSON(w *os.File, instances []*ec2Instance) error {
+	records := make([]instanceRecord, 0, len(instances))
+	for _, i := range instances {
+		records = append(records, toRecord(i))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+// firstNonEmpty returns the first non-nil string among candidates, used to
+// pick between the EC2-instance-backed and Fargate-ENI-backed IP fields.
+func firstNonEmpty(candidates ...*string) string {
+	for _, c := range candidates {
+		if c != nil {
+			return *c
+		}
+	}
+	return ""
+}
+
+func printInstancesTSV(w *os.File, instances []*ec2Instance) {
+	for _, i := range instances {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			aws.StringValue(i.clusterArn), aws.StringValue(i.serviceArn), aws.StringValue(i.taskArn),
+			aws.StringValue(i.ec2InstanceId), aws.StringValue(i.eniId),
+			firstNonEmpty(i.privateIpAddress, i.privateIpv4), firstNonEmpty(i.publicIpAddress, i.publicIpv4),
+			aws.StringValue(i.availabilityZone), aws.StringValue(i.instanceType))
+	}
+}
+
+func printInstancesTable(w *os.File, instances []*ec2Instance) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLUSTER\tSERVICE\tTASK\tEC2 INSTANCE\tENI\tPRIVATE IP\tPUBLIC IP\tAZ\tTYPE")
+	for _, i := range instances {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			aws.StringValue(i.clusterArn), aws.StringValue(i.serviceArn), aws.StringValue(i.taskArn),
+			aws.StringValue(i.ec2InstanceId), aws.StringValue(i.eniId),
+			firstNonEmpty(i.privateIpAddress, i.privateIpv4), firstNonEmpty(i.publicIpAddress, i.publicIpv4),
+			aws.StringValue(i.availabilityZone), aws.StringValue(i.instanceType))
+	}
+	tw.Flush()
+}
+
+// changeEvent is a single added/removed/updated record emitted as a JSON
+// line in watch mode.
+type changeEvent struct {
+	Type   string         `json:"type"`
+	Record instanceRecord `json:"record"`
+}
+
+// snapshotInstances indexes instances by task ARN, the one field every
+// record (EC2-backed or Fargate) is guaranteed to carry.
+func snapshotInstances(instances []*ec2Instance) map[string]instanceRecord {
+	snapshot := make(map[string]instanceRecord, len(instances))
+	for _, i := range instances {
+		r := toRecord(i)
+		snapshot[r.TaskArn] = r
+	}
+	return snapshot
+}
+
+// diffSnapshots compares two polls of snapshotInstances and reports what
+// changed; event.Type is one of "added", "removed" or "updated".
+func diffSnapshots(prev, curr map[string]instanceRecord) []changeEvent {
+	var events []changeEvent
+	for taskArn, r := range curr {
+		if old, ok := prev[taskArn]; !ok {
+			events = append(events, changeEvent{Type: "added", Record: r})
+		} else if !reflect.DeepEqual(old, r) {
+			events = append(events, changeEvent{Type: "updated", Record: r})
+		}
+	}
+	for taskArn, r := range prev {
+		if _, ok := curr[taskArn]; !ok {
+			events = append(events, changeEvent{Type: "removed", Record: r})
+		}
+	}
+	return events
+}
+
+// scanOnce runs the full services -> tasks -> container instances -> EC2
+// pipeline once, serving ListClusters from cache when possible. Task lists
+// are always listed fresh; see listTasks.
+func scanOnce(ctx aws.Context, client *ecs.ECS, ec2Client *ec2.EC2, serviceQuery, launchType string, clusterFilters []string, tagFilters map[string]string, cache *gocache.Cache, opts scanOptions) ([]*ec2Instance, error) {
+	services, err := searchServices(ctx, client, serviceQuery, clusterFilters, tagFilters, cache, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := searchTasks(ctx, client, services, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	containerInstances, err := searchContainerInstances(ctx, client, ec2Client, tasks, launchType, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return searchEc2Instances(ctx, ec2Client, containerInstances, opts)
+}
+
+// runWatch polls ECS every interval, caching the ListClusters output between
+// polls (clusters rarely change, so this is safe to cache), and emits
+// added/removed/updated records to stdout as JSON lines instead of
+// reprinting the full scan every time. Task lists are never cached: they're
+// exactly what's being diffed, so serving a stale one could mask task churn
+// within an interval. It returns when ctx is canceled (e.g. by a
+// SIGINT/SIGTERM the caller wired into ctx).
+func runWatch(ctx aws.Context, client *ecs.ECS, ec2Client *ec2.EC2, serviceQuery, launchType string, clusterFilters []string, tagFilters map[string]string, interval time.Duration, opts scanOptions) error {
+	// Keep clusters for several intervals so the following polls find them
+	// cached instead of expiring right as the next tick fires.
+	cache := gocache.New(5*interval, 10*interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	enc := json.NewEncoder(os.Stdout)
+	prev := make(map[string]instanceRecord)
+	for {
+		instances, err := scanOnce(ctx, client, ec2Client, serviceQuery, launchType, clusterFilters, tagFilters, cache, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		curr := snapshotInstances(instances)
+		for _, event := range diffSnapshots(prev, curr) {
+			if err := enc.Encode(event); err != nil {
+				return err
+			}
+		}
+		prev = curr
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// stringSliceFlag collects repeated occurrences of a flag (e.g. --cluster a
+// --cluster b) into a slice, in the order given.
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// parseTagFilters parses a list of "key=value" strings, as passed via
+// repeated --tag flags, into a map.
+func parseTagFilters(tags []string) (map[string]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	filters := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --tag %q, must be key=value", tag)
+		}
+		filters[key] = value
+	}
+	return filters, nil
+}
+
 func main() {
 	var serviceQuery string
-	flag.StringVar(&serviceQuery, "query", "", "Query used to filter services")
+	var output string
+	var launchType string
+	var watch bool
+	var interval time.Duration
+	var concurrency int
+	var partial bool
+	var clusterFilters stringSliceFlag
+	var tagFilters stringSliceFlag
+	flag.StringVar(&serviceQuery, "query", "", "Query used to filter services: an exact full ARN match, a glob (* and ?, e.g. *myservice*), or a /regex/")
+	flag.StringVar(&output, "output", "table", "Output format: json, table or tsv")
+	flag.StringVar(&launchType, "launch-type", launchTypeAll, "Launch type to scan: ec2, fargate or all")
+	flag.BoolVar(&watch, "watch", false, "Keep polling ECS and emit added/removed/updated records as JSON lines")
+	flag.DurationVar(&interval, "interval", 30*time.Second, "Polling interval when --watch is set")
+	flag.IntVar(&concurrency, "concurrency", defaultConcurrency, "Maximum number of cluster/service scans or Describe* batches in flight at once")
+	flag.BoolVar(&partial, "partial", false, "Flush whatever was collected so far instead of aborting when a cluster/service/batch fails")
+	flag.Var(&clusterFilters, "cluster", "Restrict the scan to this cluster (name or ARN); may be repeated")
+	flag.Var(&tagFilters, "tag", "Only keep services carrying this key=value tag; may be repeated")
 	flag.Parse()
 
 	if len(serviceQuery) == 0 {
@@ -143,6 +1100,24 @@ func main() {
 		os.Exit(1)
 	}
 
+	tags, err := parseTagFilters(tagFilters)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	switch launchType {
+	case launchTypeEc2, launchTypeFargate, launchTypeAll:
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid --launch-type %q, must be one of ec2, fargate, all\n", launchType)
+		os.Exit(1)
+	}
+
+	if concurrency < 1 {
+		fmt.Fprintf(os.Stderr, "--concurrency must be at least 1\n")
+		os.Exit(1)
+	}
+
 	s, err := session.NewSessionWithOptions(session.Options{
 		SharedConfigState: session.SharedConfigEnable,
 	})
@@ -153,27 +1128,29 @@ func main() {
 	}
 
 	c := ecs.New(s)
-	ctx := aws.BackgroundContext()
+	ec2Client := ec2.New(s)
 
-	services, err := searchServices(ctx, c, serviceQuery)
-	if err != nil {
-		fmt.Fprint(os.Stderr, err)
-		os.Exit(1)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := scanOptions{concurrency: concurrency, partial: partial}
+
+	if watch {
+		if err := runWatch(ctx, c, ec2Client, serviceQuery, launchType, clusterFilters, tags, interval, opts); err != nil {
+			fmt.Fprint(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	tasks, err := searchTasks(ctx, c, services)
+	instances, err := scanOnce(ctx, c, ec2Client, serviceQuery, launchType, clusterFilters, tags, nil, opts)
 	if err != nil {
 		fmt.Fprint(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	containerInstances, err := searchContainerInstances(ctx, c, tasks)
-	if err != nil {
+	if err := printInstances(os.Stdout, instances, output); err != nil {
 		fmt.Fprint(os.Stderr, err)
 		os.Exit(1)
 	}
-
-	for _, ci := range containerInstances {
-		fmt.Printf("%s\n%s\n%s\n%s\n%s\n\n", *ci.clusterArn, *ci.serviceArn, *ci.taskArn, *ci.containerInstanceArn, *ci.ec2InstanceId)
-	}
 }